@@ -3,14 +3,23 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strings"
 )
 
 // === Input Structures ===
 
+// Edge mirrors topological-sort's Edge: a dependency target plus the wait
+// condition the deployer must observe on it before moving on.
+type Edge struct {
+	Target    string `json:"target"`
+	Condition string `json:"condition"`
+}
+
 type Override struct {
 	ServiceName  string `json:"serviceName"`
 	Branch       string `json:"branch,omitempty"`
@@ -23,25 +32,40 @@ type Override struct {
 type LocalConfig struct {
 	ServiceName         string     `json:"serviceName"`
 	DependencyOverrides []Override `json:"dependencyOverrides"`
+	// ChangedServices are services the operator has modified locally; in
+	// impact mode their transitive dependents are redeployed alongside the
+	// usual root-service closure. See also the -impacted-by flag.
+	ChangedServices []string `json:"changedServices,omitempty"`
 }
 
 // Master manifest includes both order and graph
 type MasterManifest struct {
 	DeploymentOrder         []DeployableService `json:"DeploymentOrder"`
-	DependencyAdjacencyList map[string][]string `json:"DependencyAdjacencyList"`
+	DependencyAdjacencyList map[string][]Edge   `json:"DependencyAdjacencyList"`
 }
 
 type DeployableService struct {
-	ServiceName string   `json:"serviceName"`
-	Repository  string   `json:"repository"`
-	Manifest    string   `json:"manifest"`
-	DevLocal    string   `json:"devLocal"`
-	DependsOn   []string `json:"dependsOn"`
-	Branch      string   `json:"branch"`
-	OriginalIdx int      `json:"-"` // not serialized, used for sorting
+	ServiceName string `json:"serviceName"`
+	Repository  string `json:"repository"`
+	Manifest    string `json:"manifest"`
+	DevLocal    string `json:"devLocal"`
+	DependsOn   []Edge `json:"dependsOn"`
+	Branch      string `json:"branch"`
+	OriginalIdx int    `json:"-"` // not serialized, used for sorting
+}
+
+// LocalDeploymentPlan is what gets written to local-deployment-plan.json:
+// the filtered service list plus the wave grouping it should be deployed in.
+type LocalDeploymentPlan struct {
+	Services []DeployableService `json:"services"`
+	Waves    [][]string          `json:"waves"`
 }
 
 func main() {
+	impactedBy := flag.String("impacted-by", "", "comma-separated list of changed services; redeploy their transitive dependents in addition to the root service and its own dependencies")
+	relaxStarted := flag.Bool("relax-started", false, "allow a \"started\"-condition dependency to share a wave with its dependent, instead of always waiting a wave behind it")
+	flag.Parse()
+
 	// Read master manifest
 	masterData, err := os.ReadFile("deployment-order.json")
 	if err != nil {
@@ -83,14 +107,31 @@ func main() {
 		log.Fatalf("❌ Invalid local config JSON: %v", err)
 	}
 
-	// find all the services which are dependent on this serivce
-
 	// Build override map
 	overrideMap := make(map[string]Override)
 	for _, o := range local.DependencyOverrides {
 		overrideMap[o.ServiceName] = o
 	}
 
+	// Build reverse adjacency list once: dep -> services that depend on it.
+	// Used below to find all the services which are dependent on a changed
+	// service.
+	reverseGraph := make(map[string][]string)
+	for service, deps := range graph {
+		for _, dep := range deps {
+			reverseGraph[dep.Target] = append(reverseGraph[dep.Target], service)
+		}
+	}
+
+	changedServices := append([]string(nil), local.ChangedServices...)
+	if *impactedBy != "" {
+		for _, svc := range strings.Split(*impactedBy, ",") {
+			if svc = strings.TrimSpace(svc); svc != "" {
+				changedServices = append(changedServices, svc)
+			}
+		}
+	}
+
 	// Set of services to deploy
 	deploySet := make(map[string]bool)
 
@@ -102,6 +143,17 @@ func main() {
 	deploySet[root] = true
 	addTransitiveDeps(root, graph, deploySet)
 
+	// Step 1b: Impact mode — for every service the operator says they
+	// changed, pull in everything that transitively depends on it, so the
+	// redeploy plan covers exactly what the change affects.
+	for _, changed := range changedServices {
+		if _, exists := serviceMap[changed]; !exists {
+			log.Fatalf("❌ Changed service '%s' not found in master list", changed)
+		}
+		deploySet[changed] = true
+		addTransitiveDependents(changed, reverseGraph, deploySet)
+	}
+
 	// Step 2: Add force-deploy services and their deps
 	for _, override := range overrideMap {
 		if override.ForceDeploy {
@@ -152,19 +204,50 @@ func main() {
 		}
 	}
 
-	// Step 5: Print result
-	fmt.Println("🚀 Final Deployment Plan (in topological order):")
-	for i, svc := range finalList {
-		fmt.Printf("%d. %s\n", i+1, svc.ServiceName)
-		fmt.Printf("   Repo: %s\n", svc.Repository)
-		fmt.Printf("   Branch: %s\n", svc.Branch)
-		fmt.Printf("   Manifest: %s\n", svc.Manifest)
-		fmt.Printf("   DevLocal: %s\n", svc.DevLocal)
-		fmt.Println()
+	// Step 5: Re-run the wave scheduler on the induced subgraph (edges
+	// between two services that both made the cut), so the operator gets a
+	// parallel deploy plan instead of just a flat order.
+	inducedGraph := make(map[string][]Edge, len(finalList))
+	for _, svc := range finalList {
+		var deps []Edge
+		for _, dep := range svc.DependsOn {
+			if deploySet[dep.Target] {
+				deps = append(deps, dep)
+			}
+		}
+		inducedGraph[svc.ServiceName] = deps
+	}
+
+	waves, err := topoWaves(inducedGraph, *relaxStarted)
+	if err != nil {
+		log.Fatalf("🚫 Wave scheduling failed: %v", err)
+	}
+
+	// Step 6: Print result
+	finalByName := make(map[string]DeployableService, len(finalList))
+	for _, svc := range finalList {
+		finalByName[svc.ServiceName] = svc
+	}
+	fmt.Println("🚀 Final Deployment Plan (in waves, deploy each wave in parallel):")
+	for i, wave := range waves {
+		fmt.Printf("Wave %d:\n", i+1)
+		for _, serviceName := range wave {
+			svc := finalByName[serviceName]
+			fmt.Printf("  - %s\n", svc.ServiceName)
+			fmt.Printf("      Repo: %s\n", svc.Repository)
+			fmt.Printf("      Branch: %s\n", svc.Branch)
+			fmt.Printf("      Manifest: %s\n", svc.Manifest)
+			fmt.Printf("      DevLocal: %s\n", svc.DevLocal)
+		}
+	}
+
+	plan := LocalDeploymentPlan{
+		Services: finalList,
+		Waves:    waves,
 	}
 
 	// Optional: Save to file
-	outputData, err := json.MarshalIndent(finalList, "", "  ")
+	outputData, err := json.MarshalIndent(plan, "", "  ")
 	if err != nil {
 		log.Fatalf("❌ Failed to generate output JSON: %v", err)
 	}
@@ -173,11 +256,121 @@ func main() {
 }
 
 // Recursively add all dependencies
-func addTransitiveDeps(service string, graph map[string][]string, set map[string]bool) {
+func addTransitiveDeps(service string, graph map[string][]Edge, set map[string]bool) {
 	for _, dep := range graph[service] {
-		if !set[dep] {
-			set[dep] = true
-			addTransitiveDeps(dep, graph, set)
+		if !set[dep.Target] {
+			set[dep.Target] = true
+			addTransitiveDeps(dep.Target, graph, set)
 		}
 	}
 }
+
+// Recursively add all services that transitively depend on service, by
+// walking the reverse adjacency list (dep -> dependents).
+func addTransitiveDependents(service string, reverseGraph map[string][]string, set map[string]bool) {
+	for _, dependent := range reverseGraph[service] {
+		if !set[dependent] {
+			set[dependent] = true
+			addTransitiveDependents(dependent, reverseGraph, set)
+		}
+	}
+}
+
+// topoWaves groups graph into "waves": batches of services that have no
+// unmet dependencies on each other, so every service in a wave can be
+// deployed in parallel while still respecting dependencies across waves.
+// It implements Kahn's algorithm: seed a queue with every zero-in-degree
+// node, drain the whole queue as one wave per round, and enqueue any
+// successor whose in-degree hits zero as a result.
+//
+// An edge with condition "completed" always forces its dependent into a
+// strictly later wave than the dependency, same as before Edge existed.
+// When relaxStarted is true, an edge with condition "started" is treated
+// as satisfied as soon as the dependency is itself placed in a wave,
+// letting the dependent join that very same wave instead of waiting for
+// the next one.
+func topoWaves(graph map[string][]Edge, relaxStarted bool) ([][]string, error) {
+	nodes := make(map[string]bool)
+	for node, deps := range graph {
+		nodes[node] = true
+		for _, dep := range deps {
+			nodes[dep.Target] = true
+		}
+	}
+
+	hardIn := make(map[string]int, len(nodes))
+	softIn := make(map[string]int, len(nodes))
+	hardDependents := make(map[string][]string)
+	softDependents := make(map[string][]string)
+
+	for node := range nodes {
+		for _, dep := range graph[node] {
+			if relaxStarted && dep.Condition == "started" {
+				softIn[node]++
+				softDependents[dep.Target] = append(softDependents[dep.Target], node)
+			} else {
+				hardIn[node]++
+				hardDependents[dep.Target] = append(hardDependents[dep.Target], node)
+			}
+		}
+	}
+
+	scheduled := make(map[string]bool, len(nodes))
+	var waves [][]string
+
+	for {
+		var wave []string
+		frontier := make(map[string]bool)
+		for node := range nodes {
+			if !scheduled[node] && hardIn[node] == 0 && softIn[node] == 0 {
+				wave = append(wave, node)
+				frontier[node] = true
+			}
+		}
+		if len(wave) == 0 {
+			break
+		}
+
+		// fixpoint: a node whose only remaining dependency is a "started"
+		// edge to something already in this wave can join this same wave
+		for len(frontier) > 0 {
+			next := make(map[string]bool)
+			for node := range frontier {
+				for _, dependent := range softDependents[node] {
+					if scheduled[dependent] || frontier[dependent] {
+						continue
+					}
+					softIn[dependent]--
+					if hardIn[dependent] == 0 && softIn[dependent] == 0 {
+						wave = append(wave, dependent)
+						next[dependent] = true
+					}
+				}
+			}
+			frontier = next
+		}
+
+		sort.Strings(wave) // deterministic ordering within a wave
+		waves = append(waves, wave)
+
+		for _, node := range wave {
+			scheduled[node] = true
+			for _, dependent := range hardDependents[node] {
+				hardIn[dependent]--
+			}
+		}
+	}
+
+	if len(scheduled) != len(nodes) {
+		var stuck []string
+		for node := range nodes {
+			if !scheduled[node] {
+				stuck = append(stuck, node)
+			}
+		}
+		sort.Strings(stuck)
+		return nil, fmt.Errorf("❌ cycle detected among services: %s", strings.Join(stuck, ", "))
+	}
+
+	return waves, nil
+}
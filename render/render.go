@@ -0,0 +1,211 @@
+// render.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Edge mirrors topological-sort's Edge: a dependency target plus the wait
+// condition the deployer must observe on it.
+type Edge struct {
+	Target    string `yaml:"target" json:"target"`
+	Condition string `yaml:"condition" json:"condition"`
+}
+
+// Output structure for deployment order (mirrors topological-sort's shape,
+// since that's what deployment-order.yml was written from)
+type DeployDependency struct {
+	ServiceName string `yaml:"serviceName"`
+	Repository  string `yaml:"repository"`
+	Manifest    string `yaml:"manifest"`
+	DevLocal    string `yaml:"devLocal"`
+	DependsOn   []Edge `yaml:"dependsOn"`
+	Branch      string `yaml:"branch"`
+}
+
+type DependsOn struct {
+	DependsOn []Edge `yaml:"dependsOn"`
+}
+
+type FinalDeploymentList struct {
+	DeploymentOrder         []DeployDependency   `yaml:"deploymentorder"`
+	DependencyAdjacencyList map[string]DependsOn `yaml:"dependencyadjacencylist"`
+	DeploymentWaves         [][]DeployDependency  `yaml:"deploymentWaves,omitempty"`
+}
+
+
+// templateOutFlag collects repeated "--template"/"--out" flags in the order
+// they were given; the i-th --template is rendered to the i-th --out.
+type templateOutFlag []string
+
+func (t *templateOutFlag) String() string { return fmt.Sprintf("%v", []string(*t)) }
+func (t *templateOutFlag) Set(value string) error {
+	*t = append(*t, value)
+	return nil
+}
+
+// serviceTemplateFlag collects repeated "--service-template name=path" flags.
+type serviceTemplateFlag map[string]string
+
+func (s serviceTemplateFlag) String() string { return fmt.Sprintf("%v", map[string]string(s)) }
+func (s serviceTemplateFlag) Set(value string) error {
+	name, path, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("--service-template must be NAME=path, got %q", value)
+	}
+	s[name] = path
+	return nil
+}
+
+// renderPlan is what a template file executes against: the full deployment
+// plan, the waves it can be split across, and any union grouping, plus
+// helper funcs registered in its FuncMap.
+type renderPlan struct {
+	Plan   FinalDeploymentList
+	Groups map[string]string // service -> union root, empty if -u wasn't given
+
+	waveOf           map[string]int
+	serviceTemplates serviceTemplateFlag
+}
+
+func main() {
+	deploymentFile := flag.String("d", "./deployment-order.yml", "FinalDeploymentList YAML produced by topoSort")
+	unionFile := flag.String("u", "", "optional union.yml grouping file, to expose {{ group \"svc\" }}")
+
+	var templates, outs templateOutFlag
+	flag.Var(&templates, "template", "template file to render (repeatable; paired by position with --out)")
+	flag.Var(&outs, "out", "output file to write a rendered template to (repeatable; paired by position with --template)")
+
+	serviceTemplates := make(serviceTemplateFlag)
+	flag.Var(serviceTemplates, "service-template", "NAME=path override template for a single service, usable via {{ serviceTemplate \"NAME\" . }} (repeatable)")
+
+	flag.Parse()
+
+	if len(templates) == 0 || len(templates) != len(outs) {
+		log.Fatalf("❌ --template and --out must both be given, the same number of times (got %d templates, %d outs)", len(templates), len(outs))
+	}
+
+	data, err := os.ReadFile(*deploymentFile)
+	if err != nil {
+		log.Fatalf("❌ Failed to read %s: %v", *deploymentFile, err)
+	}
+	var plan FinalDeploymentList
+	if err := yaml.Unmarshal(data, &plan); err != nil {
+		log.Fatalf("❌ Invalid YML in %s: %v", *deploymentFile, err)
+	}
+
+	groups := make(map[string]string)
+	if *unionFile != "" {
+		unionData, err := os.ReadFile(*unionFile)
+		if err != nil {
+			log.Fatalf("❌ Failed to read %s: %v", *unionFile, err)
+		}
+		if err := yaml.Unmarshal(unionData, &groups); err != nil {
+			log.Fatalf("❌ Invalid YML in %s: %v", *unionFile, err)
+		}
+	}
+
+	waveOf := make(map[string]int)
+	for i, wave := range plan.DeploymentWaves {
+		for _, svc := range wave {
+			waveOf[svc.ServiceName] = i
+		}
+	}
+
+	rp := &renderPlan{
+		Plan:             plan,
+		Groups:           groups,
+		waveOf:           waveOf,
+		serviceTemplates: serviceTemplates,
+	}
+
+	for i, tmplPath := range templates {
+		if err := rp.renderOne(tmplPath, outs[i]); err != nil {
+			log.Fatalf("❌ Failed to render %s: %v", tmplPath, err)
+		}
+		fmt.Printf("✅ Rendered %s -> %s\n", tmplPath, outs[i])
+	}
+}
+
+func (rp *renderPlan) renderOne(tmplPath, outPath string) error {
+	tmpl, err := template.New(filepath.Base(tmplPath)).Funcs(rp.funcMap()).ParseFiles(tmplPath)
+	if err != nil {
+		return fmt.Errorf("parsing template: %w", err)
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("creating output file: %w", err)
+	}
+	defer out.Close()
+
+	if err := tmpl.ExecuteTemplate(out, filepath.Base(tmplPath), rp); err != nil {
+		return fmt.Errorf("executing template: %w", err)
+	}
+	return nil
+}
+
+// funcMap exposes the helpers a CD-artifact template needs to turn the plan
+// into Argo DAG steps, a Helm dependencies block, a bash script with wait
+// barriers between waves, or whatever else the user's template targets.
+func (rp *renderPlan) funcMap() template.FuncMap {
+	return template.FuncMap{
+		"join": func(sep string, items []string) string {
+			return strings.Join(items, sep)
+		},
+		"indent": func(spaces int, text string) string {
+			pad := strings.Repeat(" ", spaces)
+			lines := strings.Split(text, "\n")
+			for i, line := range lines {
+				if line != "" {
+					lines[i] = pad + line
+				}
+			}
+			return strings.Join(lines, "\n")
+		},
+		"hasEdge": func(from, to string) bool {
+			for _, dep := range rp.Plan.DependencyAdjacencyList[from].DependsOn {
+				if dep.Target == to {
+					return true
+				}
+			}
+			return false
+		},
+		"wave": func(service string) int {
+			if i, ok := rp.waveOf[service]; ok {
+				return i
+			}
+			return -1
+		},
+		"group": func(service string) string {
+			return rp.Groups[service]
+		},
+		// serviceTemplate renders the override template registered for
+		// service (via --service-template NAME=path) against data, letting
+		// a master template delegate per-service customization without
+		// hard-coding every service's quirks inline.
+		"serviceTemplate": func(service string, data any) (string, error) {
+			path, ok := rp.serviceTemplates[service]
+			if !ok {
+				return "", nil
+			}
+			tmpl, err := template.New(filepath.Base(path)).Funcs(rp.funcMap()).ParseFiles(path)
+			if err != nil {
+				return "", fmt.Errorf("parsing override template for %s: %w", service, err)
+			}
+			var sb strings.Builder
+			if err := tmpl.ExecuteTemplate(&sb, filepath.Base(path), data); err != nil {
+				return "", fmt.Errorf("executing override template for %s: %w", service, err)
+			}
+			return sb.String(), nil
+		},
+	}
+}
@@ -0,0 +1,204 @@
+// compose-import.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ComposeBuild mirrors the subset of a compose service's `build` block we care about.
+type ComposeBuild struct {
+	Context string            `yaml:"context"`
+	Args    map[string]string `yaml:"args"`
+}
+
+// ComposeDependency is the map-form entry under `depends_on`.
+type ComposeDependency struct {
+	Condition string `yaml:"condition"`
+}
+
+// ComposeService is one entry of a compose file's top-level `services` map.
+type ComposeService struct {
+	Build     ComposeBuild
+	Image     string
+	DependsOn map[string]ComposeDependency
+}
+
+// UnmarshalYAML accepts both forms of `depends_on`: a short list of service
+// names, or a map of service name to `{condition: ...}`. Bare list entries
+// default to "service_started", matching compose's own default.
+func (s *ComposeService) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		Build     ComposeBuild `yaml:"build"`
+		Image     string       `yaml:"image"`
+		DependsOn yaml.Node    `yaml:"depends_on"`
+	}
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	s.Build = raw.Build
+	s.Image = raw.Image
+	s.DependsOn = make(map[string]ComposeDependency)
+
+	switch raw.DependsOn.Kind {
+	case 0:
+		// depends_on omitted entirely
+	case yaml.SequenceNode:
+		var names []string
+		if err := raw.DependsOn.Decode(&names); err != nil {
+			return err
+		}
+		for _, name := range names {
+			s.DependsOn[name] = ComposeDependency{Condition: "service_started"}
+		}
+	case yaml.MappingNode:
+		var deps map[string]ComposeDependency
+		if err := raw.DependsOn.Decode(&deps); err != nil {
+			return err
+		}
+		for name, dep := range deps {
+			if dep.Condition == "" {
+				dep.Condition = "service_started"
+			}
+			s.DependsOn[name] = dep
+		}
+	default:
+		return fmt.Errorf("depends_on: unsupported YAML node kind %v", raw.DependsOn.Kind)
+	}
+
+	return nil
+}
+
+// ComposeFile is the top-level shape of a docker-compose.yml we understand.
+type ComposeFile struct {
+	Services map[string]ComposeService `yaml:"services"`
+}
+
+// composeFileFlags collects repeated `-c` flags in the order they were given.
+type composeFileFlags []string
+
+func (c *composeFileFlags) String() string {
+	return fmt.Sprintf("%v", []string(*c))
+}
+
+func (c *composeFileFlags) Set(value string) error {
+	*c = append(*c, value)
+	return nil
+}
+
+// mergeComposeFiles deep-merges compose files in the order supplied: later
+// files override scalar fields, extend dependency/arg maps, and add services
+// that weren't present yet.
+func mergeComposeFiles(files []ComposeFile) ComposeFile {
+	merged := ComposeFile{Services: make(map[string]ComposeService)}
+	for _, f := range files {
+		for name, svc := range f.Services {
+			existing, ok := merged.Services[name]
+			if !ok {
+				merged.Services[name] = svc
+				continue
+			}
+			merged.Services[name] = mergeComposeService(existing, svc)
+		}
+	}
+	return merged
+}
+
+func mergeComposeService(base, override ComposeService) ComposeService {
+	merged := base
+
+	// merged still aliases base's maps at this point; clone them before
+	// writing overrides in, so merging doesn't mutate the earlier file's
+	// parsed service out from under it.
+	merged.Build.Args = make(map[string]string, len(base.Build.Args))
+	for k, v := range base.Build.Args {
+		merged.Build.Args[k] = v
+	}
+	merged.DependsOn = make(map[string]ComposeDependency, len(base.DependsOn))
+	for name, dep := range base.DependsOn {
+		merged.DependsOn[name] = dep
+	}
+
+	if override.Image != "" {
+		merged.Image = override.Image
+	}
+	if override.Build.Context != "" {
+		merged.Build.Context = override.Build.Context
+	}
+	for k, v := range override.Build.Args {
+		merged.Build.Args[k] = v
+	}
+	for name, dep := range override.DependsOn {
+		merged.DependsOn[name] = dep
+	}
+
+	return merged
+}
+
+// normalizeComposeCondition maps compose's depends_on condition vocabulary
+// onto the one Edge.Condition uses elsewhere (topoWaves, local-deploy),
+// so "-relax-started" applies the same whether the manifest came from a
+// compose file or was hand-authored.
+func normalizeComposeCondition(condition string) string {
+	switch condition {
+	case "service_healthy":
+		return "healthy"
+	case "service_completed_successfully":
+		return "completed"
+	default:
+		return "started"
+	}
+}
+
+// manifestFromCompose reads and merges one or more docker-compose.yml files
+// (in the order given, mirroring `docker stack deploy -c`) and translates
+// them into a Manifest, so a compose stack can stand in for a hand-authored
+// dependency-manifest.yml. The wait condition on each depends_on entry is
+// preserved on its Edge for downstream consumers (waves, local-deploy) to
+// act on.
+func manifestFromCompose(paths []string) (Manifest, error) {
+	var files []ComposeFile
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Manifest{}, fmt.Errorf("failed to read compose file %s: %w", path, err)
+		}
+		var cf ComposeFile
+		if err := yaml.Unmarshal(data, &cf); err != nil {
+			return Manifest{}, fmt.Errorf("invalid compose YAML in %s: %w", path, err)
+		}
+		files = append(files, cf)
+	}
+
+	merged := mergeComposeFiles(files)
+
+	manifest := Manifest{
+		DependencyAdjacencyList: make(map[string]DependsOn),
+		Services:                make(map[string]ServiceMetadata),
+	}
+
+	for name, svc := range merged.Services {
+		deps := make([]Edge, 0, len(svc.DependsOn))
+		for dep, info := range svc.DependsOn {
+			deps = append(deps, Edge{Target: dep, Condition: normalizeComposeCondition(info.Condition)})
+		}
+		sort.Slice(deps, func(i, j int) bool { return deps[i].Target < deps[j].Target })
+
+		manifest.DependencyAdjacencyList[name] = DependsOn{DependsOn: deps}
+
+		repo := svc.Image
+		if svc.Build.Context != "" {
+			repo = svc.Build.Context
+		}
+		manifest.Services[name] = ServiceMetadata{
+			Repository: repo,
+			Branch:     svc.Build.Args["BRANCH"],
+		}
+	}
+
+	return manifest, nil
+}
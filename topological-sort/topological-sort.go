@@ -2,9 +2,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -18,8 +20,42 @@ type ServiceMetadata struct {
 	Branch         string `yaml:"branch"`
 }
 
+// Edge is one dependency: Target is the service depended on, Condition is
+// what the deployer must observe on Target before moving on — "started",
+// "healthy", "completed", or a user-defined string. Bare strings in the
+// manifest (and compose's short-list depends_on form) unmarshal as a
+// "started" edge, so existing manifests keep working unchanged.
+type Edge struct {
+	Target    string `yaml:"target" json:"target"`
+	Condition string `yaml:"condition" json:"condition"`
+}
+
+// UnmarshalYAML accepts either a bare service name (condition defaults to
+// "started") or a mapping of {target, condition}.
+func (e *Edge) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		e.Target = value.Value
+		e.Condition = "started"
+		return nil
+	}
+
+	var raw struct {
+		Target    string `yaml:"target"`
+		Condition string `yaml:"condition"`
+	}
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	e.Target = raw.Target
+	e.Condition = raw.Condition
+	if e.Condition == "" {
+		e.Condition = "started"
+	}
+	return nil
+}
+
 type DependsOn struct {
-	DependsOn []string `yaml:"dependsOn"`
+	DependsOn []Edge `yaml:"dependsOn"`
 }
 
 // Input manifest structure
@@ -31,88 +67,282 @@ type Manifest struct {
 
 // Output structure for deployment order
 type DeployDependency struct {
-	ServiceName string   `yaml:"serviceName"`
-	Repository  string   `yaml:"repository"`
-	Manifest    string   `yaml:"manifest"`
-	DevLocal    string   `yaml:"devLocal"`
-	DependsOn   []string `yaml:"dependsOn"`
-	Branch      string   `yaml:"branch"`
+	ServiceName string `yaml:"serviceName"`
+	Repository  string `yaml:"repository"`
+	Manifest    string `yaml:"manifest"`
+	DevLocal    string `yaml:"devLocal"`
+	DependsOn   []Edge `yaml:"dependsOn"`
+	Branch      string `yaml:"branch"`
 }
 
 type FinalDeploymentList struct {
 	DeploymentOrder         []DeployDependency
 	DependencyAdjacencyList map[string]DependsOn // the dependency list which was used to generate the original deployment order
+	// DeploymentWaves groups DeploymentOrder into batches that have no unmet
+	// dependencies on each other, so each wave can be deployed in parallel.
+	DeploymentWaves [][]DeployDependency `yaml:"deploymentWaves,omitempty"`
 }
 
+// topoSort performs a full strongly-connected-components pass (Tarjan's
+// algorithm, iterative to avoid recursion limits on deep manifests) so a
+// single run reports every cycle in the graph instead of dying on the
+// first back-edge it happens to walk into.
+//
+// Each node's SCC is completed (popped off the component stack) only after
+// all of its dependencies' SCCs have been completed, so the completion
+// order is already dependency-first, as topoSort's output has always been.
+// When a component has more than one member (or a single
+// member with a self-loop), it's a cycle; every such component is
+// collected and reported together instead of stopping at the first one.
 func topoSort(graph map[string]DependsOn) ([]string, error) {
-	type state struct {
+	type frame struct {
 		node     string
-		expanded bool
+		childIdx int
 	}
 
-	visited := make(map[string]bool)
-	onPath := make(map[string]bool) // cycle detection
-	var result []string
+	index := make(map[string]int)
+	lowlink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var componentStack []string
+	var sccs [][]string
+	counter := 0
 
+	// iterate nodes in a deterministic order so errors/output are stable
+	nodes := make([]string, 0, len(graph))
 	for node := range graph {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
 
-		// if the node is already visited, skip it
-		if visited[node] {
+	for _, start := range nodes {
+		if _, seen := index[start]; seen {
 			continue
 		}
 
-		// create a local stack to save the state of the adjacent nodes
-		stack := []state{{node, false}}
+		stack := []frame{{start, 0}}
+		index[start] = counter
+		lowlink[start] = counter
+		counter++
+		componentStack = append(componentStack, start)
+		onStack[start] = true
 
-		// while the stack is not empty, pop each item from the stack and then perform dfs on that node
 		for len(stack) > 0 {
-			top := stack[len(stack)-1]
+			top := &stack[len(stack)-1]
+			deps := graph[top.node].DependsOn
+
+			if top.childIdx < len(deps) {
+				dep := deps[top.childIdx].Target
+				top.childIdx++
+
+				if _, seen := index[dep]; !seen {
+					index[dep] = counter
+					lowlink[dep] = counter
+					counter++
+					componentStack = append(componentStack, dep)
+					onStack[dep] = true
+					stack = append(stack, frame{dep, 0})
+				} else if onStack[dep] && index[dep] < lowlink[top.node] {
+					lowlink[top.node] = index[dep]
+				}
+				continue
+			}
+
+			// all of this node's dependencies are processed; pop it and
+			// propagate its lowlink up to the parent before closing out
+			// its component (if it roots one)
 			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				parent := &stack[len(stack)-1]
+				if lowlink[top.node] < lowlink[parent.node] {
+					lowlink[parent.node] = lowlink[top.node]
+				}
+			}
 
-			// if the top node is visited, skip it
-			if visited[top.node] {
-				continue
+			if lowlink[top.node] == index[top.node] {
+				var scc []string
+				for {
+					n := componentStack[len(componentStack)-1]
+					componentStack = componentStack[:len(componentStack)-1]
+					onStack[n] = false
+					scc = append(scc, n)
+					if n == top.node {
+						break
+					}
+				}
+				sccs = append(sccs, scc)
 			}
+		}
+	}
 
-			// if the top has been seen, skip it
-			if top.expanded {
-				// All children processed
-				visited[top.node] = true
-				onPath[top.node] = false
-				result = append(result, top.node)
-				continue
+	var cycles []string
+	for _, scc := range sccs {
+		isCycle := len(scc) > 1
+		if len(scc) == 1 {
+			for _, dep := range graph[scc[0]].DependsOn {
+				if dep.Target == scc[0] {
+					isCycle = true
+					break
+				}
 			}
+		}
+		if isCycle {
+			members := append([]string(nil), scc...)
+			sort.Strings(members)
+			cycles = append(cycles, fmt.Sprintf("[%s]", strings.Join(members, ", ")))
+		}
+	}
 
-			// Push back to stack for post-processing
-			stack = append(stack, state{top.node, true})
+	if len(cycles) > 0 {
+		return nil, fmt.Errorf("❌ cycle(s) detected: %s", strings.Join(cycles, ", "))
+	}
+
+	result := make([]string, len(sccs))
+	for i, scc := range sccs {
+		result[i] = scc[0]
+	}
+	return result, nil
+}
+
+// topoWaves groups graph into "waves": batches of services that have no
+// unmet dependencies on each other, so every service in a wave can be
+// deployed in parallel while still respecting dependencies across waves.
+// It implements Kahn's algorithm: seed a queue with every zero-in-degree
+// node, drain the whole queue as one wave per round, and enqueue any
+// successor whose in-degree hits zero as a result.
+//
+// An edge with condition "completed" always forces its dependent into a
+// strictly later wave than the dependency, same as before Edge existed.
+// When relaxStarted is true, an edge with condition "started" is treated
+// as satisfied as soon as the dependency is itself placed in a wave,
+// letting the dependent join that very same wave instead of waiting for
+// the next one.
+func topoWaves(graph map[string]DependsOn, relaxStarted bool) ([][]string, error) {
+	// collect every node referenced, whether it's a key or only a dependency
+	nodes := make(map[string]bool)
+	for node, deps := range graph {
+		nodes[node] = true
+		for _, dep := range deps.DependsOn {
+			nodes[dep.Target] = true
+		}
+	}
 
-			if onPath[top.node] {
-				return nil, fmt.Errorf("❌ cycle detected at service: %s", top.node)
+	hardIn := make(map[string]int, len(nodes))
+	softIn := make(map[string]int, len(nodes))
+	hardDependents := make(map[string][]string)
+	softDependents := make(map[string][]string)
+
+	for node := range nodes {
+		for _, dep := range graph[node].DependsOn {
+			if relaxStarted && dep.Condition == "started" {
+				softIn[node]++
+				softDependents[dep.Target] = append(softDependents[dep.Target], node)
+			} else {
+				hardIn[node]++
+				hardDependents[dep.Target] = append(hardDependents[dep.Target], node)
 			}
-			onPath[top.node] = true
+		}
+	}
+
+	scheduled := make(map[string]bool, len(nodes))
+	var waves [][]string
+
+	for {
+		// a node is ready once every "completed"-grade edge has resolved
+		// (its dependency finished a previous wave) and every remaining
+		// "started"-grade edge has resolved (its dependency is at least
+		// in-flight — see the fixpoint expansion below)
+		var wave []string
+		frontier := make(map[string]bool)
+		for node := range nodes {
+			if !scheduled[node] && hardIn[node] == 0 && softIn[node] == 0 {
+				wave = append(wave, node)
+				frontier[node] = true
+			}
+		}
+		if len(wave) == 0 {
+			break
+		}
 
-			for _, dep := range graph[top.node].DependsOn {
-				if !visited[dep] {
-					stack = append(stack, state{dep, false})
+		// fixpoint: a node whose only remaining dependency is a "started"
+		// edge to something already in this wave can join this same wave
+		for len(frontier) > 0 {
+			next := make(map[string]bool)
+			for node := range frontier {
+				for _, dependent := range softDependents[node] {
+					if scheduled[dependent] || frontier[dependent] {
+						continue
+					}
+					softIn[dependent]--
+					if hardIn[dependent] == 0 && softIn[dependent] == 0 {
+						wave = append(wave, dependent)
+						next[dependent] = true
+					}
 				}
 			}
+			frontier = next
+		}
+
+		sort.Strings(wave) // deterministic ordering within a wave
+		waves = append(waves, wave)
+
+		for _, node := range wave {
+			scheduled[node] = true
+			for _, dependent := range hardDependents[node] {
+				hardIn[dependent]--
+			}
 		}
 	}
 
-	return result, nil
+	if len(scheduled) != len(nodes) {
+		var stuck []string
+		for node := range nodes {
+			if !scheduled[node] {
+				stuck = append(stuck, node)
+			}
+		}
+		sort.Strings(stuck)
+		return nil, fmt.Errorf("❌ cycle detected among services: %s", strings.Join(stuck, ", "))
+	}
+
+	return waves, nil
 }
 
-func main() {
-	// Read the manifest file
-	data, err := os.ReadFile("dependency-manifest.yml")
-	if err != nil {
-		log.Fatalf("❌ Failed to read dependency-manifest.yml: %v", err)
+// edgeStrings renders edges for human-readable output, e.g. "db (healthy)".
+func edgeStrings(edges []Edge) []string {
+	out := make([]string, len(edges))
+	for i, e := range edges {
+		out[i] = fmt.Sprintf("%s (%s)", e.Target, e.Condition)
 	}
+	return out
+}
+
+func main() {
+	// -c can be repeated to point at one or more docker-compose.yml files,
+	// mirroring `docker stack deploy -c`, as an alternative to -m.
+	var composeFiles composeFileFlags
+	flag.Var(&composeFiles, "c", "docker-compose.yml file to import services from (repeatable; later files override earlier ones)")
+	manifestFile := flag.String("m", "dependency-manifest.yml", "path to the dependency manifest (ignored when -c is given)")
+	relaxStarted := flag.Bool("relax-started", false, "allow a \"started\"-condition dependency to share a wave with its dependent, instead of always waiting a wave behind it")
+	flag.Parse()
 
-	// Parse JSON
 	var manifest Manifest
-	if err := yaml.Unmarshal(data, &manifest); err != nil {
-		log.Fatalf("❌ Invalid YML in manifest: %v", err)
+	if len(composeFiles) > 0 {
+		m, err := manifestFromCompose(composeFiles)
+		if err != nil {
+			log.Fatalf("❌ Failed to build manifest from compose files: %v", err)
+		}
+		manifest = m
+	} else {
+		// Read the manifest file
+		data, err := os.ReadFile(*manifestFile)
+		if err != nil {
+			log.Fatalf("❌ Failed to read %s: %v", *manifestFile, err)
+		}
+
+		// Parse YAML
+		if err := yaml.Unmarshal(data, &manifest); err != nil {
+			log.Fatalf("❌ Invalid YML in manifest: %v", err)
+		}
 	}
 
 	// Build dependency graph
@@ -157,12 +387,30 @@ func main() {
 		fmt.Printf("\t\tRepo: %s\n", deploymentOrder[i].Repository)
 		fmt.Printf("\t\tManifest: %s\n", deploymentOrder[i].Manifest)
 		fmt.Printf("\t\tDevLocal: %s\n", deploymentOrder[i].DevLocal)
-		fmt.Printf("\t\tDepends on: %s\n", strings.Join(dependsOn, ", "))
+		fmt.Printf("\t\tDepends on: %s\n", strings.Join(edgeStrings(dependsOn), ", "))
+	}
+
+	waves, err := topoWaves(graph, *relaxStarted)
+	if err != nil {
+		log.Fatalf("🚫 Wave scheduling failed: %v", err)
+	}
+
+	byName := make(map[string]DeployDependency, len(deploymentOrder))
+	for _, svc := range deploymentOrder {
+		byName[svc.ServiceName] = svc
+	}
+
+	deploymentWaves := make([][]DeployDependency, len(waves))
+	for i, wave := range waves {
+		for _, service := range wave {
+			deploymentWaves[i] = append(deploymentWaves[i], byName[service])
+		}
 	}
 
 	finalDeployList := FinalDeploymentList{
 		DeploymentOrder:         deploymentOrder,
 		DependencyAdjacencyList: manifest.DependencyAdjacencyList,
+		DeploymentWaves:         deploymentWaves,
 	}
 
 	// Convert to JSON